@@ -0,0 +1,242 @@
+// Package classicelb attaches feed to classic ELBs (elbv1) tagged for the
+// cluster, registering this node's EC2 instance as an instance of each one.
+// It exists alongside the elb package so that clusters still on classic
+// ELBs, which some cloud providers continue to support for legacy stacks,
+// can adopt feed without migrating to ALB/NLB target groups first.
+package classicelb
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+	aws_elb "github.com/aws/aws-sdk-go/service/elb"
+	"github.com/sky-uk/feed/controller"
+)
+
+const frontendTag = "sky.uk/KubernetesClusterFrontend"
+
+// maxTagsPerCall is the maximum number of load balancer names the
+// DescribeTags API accepts in a single call.
+const maxTagsPerCall = 20
+
+// awsElb is the subset of the classic elb API that this package depends on.
+type awsElb interface {
+	DescribeLoadBalancers(input *aws_elb.DescribeLoadBalancersInput) (*aws_elb.DescribeLoadBalancersOutput, error)
+	DescribeTags(input *aws_elb.DescribeTagsInput) (*aws_elb.DescribeTagsOutput, error)
+	RegisterInstancesWithLoadBalancer(input *aws_elb.RegisterInstancesWithLoadBalancerInput) (*aws_elb.RegisterInstancesWithLoadBalancerOutput, error)
+	DeregisterInstancesFromLoadBalancer(input *aws_elb.DeregisterInstancesFromLoadBalancerInput) (*aws_elb.DeregisterInstancesFromLoadBalancerOutput, error)
+}
+
+// metadata is the subset of the ec2metadata API that this package depends on.
+type metadata interface {
+	Available() bool
+	Region() (string, error)
+	GetInstanceIdentityDocument() (ec2metadata.EC2InstanceIdentityDocument, error)
+}
+
+// LoadBalancerDetails describes a front end classic ELB tagged for this
+// cluster.
+type LoadBalancerDetails struct {
+	Name         string
+	DNSName      string
+	HostedZoneID string
+	Scheme       string
+}
+
+type classicelb struct {
+	awsElb         awsElb
+	metadata       metadata
+	clusterName    string
+	expectedNumber int
+	drainDelay     time.Duration
+
+	mu           sync.Mutex
+	registered   []string
+	instanceID   string
+	readinessErr error
+}
+
+// New creates an updater that attaches feed to the classic ELBs tagged
+// sky.uk/KubernetesClusterFrontend for clusterFrontEndNameValue, registering
+// this node's EC2 instance as an instance of each one.
+func New(region string, clusterFrontEndNameValue string, expectedNumber int, drainDelay time.Duration) (controller.Updater, error) {
+	if clusterFrontEndNameValue == "" {
+		return nil, errors.New("unable to create classic ELB updater: cluster name must not be blank")
+	}
+
+	awsSession, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create classic ELB updater: %v", err)
+	}
+
+	return &classicelb{
+		awsElb:         aws_elb.New(awsSession),
+		metadata:       ec2metadata.New(awsSession),
+		clusterName:    clusterFrontEndNameValue,
+		expectedNumber: expectedNumber,
+		drainDelay:     drainDelay,
+	}, nil
+}
+
+func (e *classicelb) Start() error {
+	return nil
+}
+
+func (e *classicelb) Stop() error {
+	e.mu.Lock()
+	registered := e.registered
+	instanceID := e.instanceID
+	e.mu.Unlock()
+
+	var failed bool
+	for _, name := range registered {
+		_, err := e.awsElb.DeregisterInstancesFromLoadBalancer(&aws_elb.DeregisterInstancesFromLoadBalancerInput{
+			LoadBalancerName: aws.String(name),
+			Instances:        []*aws_elb.Instance{{InstanceId: aws.String(instanceID)}},
+		})
+		if err != nil {
+			failed = true
+		}
+	}
+
+	time.Sleep(e.drainDelay)
+
+	if failed {
+		return errors.New("at least one ELB failed to detach")
+	}
+	return nil
+}
+
+func (e *classicelb) Update(controller.IngressEntries) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	doc, err := e.metadata.GetInstanceIdentityDocument()
+	if err != nil {
+		e.readinessErr = fmt.Errorf("unable to query ec2 metadata service for InstanceId: %v", err)
+		return e.readinessErr
+	}
+	e.instanceID = doc.InstanceID
+
+	frontends, err := FindFrontEndElbs(e.awsElb, e.clusterName)
+	if err != nil {
+		e.readinessErr = err
+		return err
+	}
+
+	if len(frontends) != e.expectedNumber {
+		e.readinessErr = fmt.Errorf("expected ELBs: %d actual: %d", e.expectedNumber, len(frontends))
+		return e.readinessErr
+	}
+
+	var registered []string
+	for _, frontend := range frontends {
+		_, err := e.awsElb.RegisterInstancesWithLoadBalancer(&aws_elb.RegisterInstancesWithLoadBalancerInput{
+			LoadBalancerName: aws.String(frontend.Name),
+			Instances:        []*aws_elb.Instance{{InstanceId: aws.String(doc.InstanceID)}},
+		})
+		if err != nil {
+			e.readinessErr = fmt.Errorf("unable to register instance %s with elb %s: %v", doc.InstanceID, frontend.Name, err)
+			return e.readinessErr
+		}
+		registered = append(registered, frontend.Name)
+	}
+
+	e.registered = registered
+	e.readinessErr = nil
+	return nil
+}
+
+func (e *classicelb) Health() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.readinessErr
+}
+
+func (e *classicelb) String() string {
+	return "classic elb frontend"
+}
+
+// FindFrontEndElbs finds the classic ELBs tagged
+// sky.uk/KubernetesClusterFrontend for clusterName, keyed by scheme.
+func FindFrontEndElbs(e awsElb, clusterName string) (map[string]*LoadBalancerDetails, error) {
+	lbs, err := describeLoadBalancers(e)
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe load balancers: %v", err)
+	}
+
+	tagsByName, err := describeTags(e, lbs)
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe tags: %v", err)
+	}
+
+	frontends := make(map[string]*LoadBalancerDetails)
+	for _, l := range lbs {
+		tags, ok := tagsByName[aws.StringValue(l.LoadBalancerName)]
+		if !ok || !hasClusterTag(tags, clusterName) {
+			continue
+		}
+		frontends[aws.StringValue(l.Scheme)] = &LoadBalancerDetails{
+			Name:         aws.StringValue(l.LoadBalancerName),
+			DNSName:      aws.StringValue(l.DNSName),
+			HostedZoneID: aws.StringValue(l.CanonicalHostedZoneNameID),
+			Scheme:       aws.StringValue(l.Scheme),
+		}
+	}
+
+	return frontends, nil
+}
+
+func describeLoadBalancers(e awsElb) ([]*aws_elb.LoadBalancerDescription, error) {
+	var all []*aws_elb.LoadBalancerDescription
+	input := &aws_elb.DescribeLoadBalancersInput{}
+	for {
+		output, err := e.DescribeLoadBalancers(input)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, output.LoadBalancerDescriptions...)
+		if output.NextMarker == nil {
+			return all, nil
+		}
+		input = &aws_elb.DescribeLoadBalancersInput{Marker: output.NextMarker}
+	}
+}
+
+func describeTags(e awsElb, lbs []*aws_elb.LoadBalancerDescription) (map[string][]*aws_elb.Tag, error) {
+	tagsByName := make(map[string][]*aws_elb.Tag)
+	for start := 0; start < len(lbs); start += maxTagsPerCall {
+		end := start + maxTagsPerCall
+		if end > len(lbs) {
+			end = len(lbs)
+		}
+
+		names := make([]*string, 0, end-start)
+		for _, l := range lbs[start:end] {
+			names = append(names, l.LoadBalancerName)
+		}
+
+		output, err := e.DescribeTags(&aws_elb.DescribeTagsInput{LoadBalancerNames: names})
+		if err != nil {
+			return nil, err
+		}
+		for _, description := range output.TagDescriptions {
+			tagsByName[aws.StringValue(description.LoadBalancerName)] = description.Tags
+		}
+	}
+	return tagsByName, nil
+}
+
+func hasClusterTag(tags []*aws_elb.Tag, clusterName string) bool {
+	for _, t := range tags {
+		if aws.StringValue(t.Key) == frontendTag && aws.StringValue(t.Value) == clusterName {
+			return true
+		}
+	}
+	return false
+}
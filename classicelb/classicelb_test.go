@@ -0,0 +1,178 @@
+package classicelb
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	aws_elb "github.com/aws/aws-sdk-go/service/elb"
+	"github.com/sky-uk/feed/controller"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	clusterName       = "cluster_name"
+	region            = "eu-west-1"
+	elbInternalScheme = "internal"
+)
+
+type fakeElb struct {
+	mock.Mock
+}
+
+func (m *fakeElb) DescribeLoadBalancers(input *aws_elb.DescribeLoadBalancersInput) (*aws_elb.DescribeLoadBalancersOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*aws_elb.DescribeLoadBalancersOutput), args.Error(1)
+}
+
+func (m *fakeElb) DescribeTags(input *aws_elb.DescribeTagsInput) (*aws_elb.DescribeTagsOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*aws_elb.DescribeTagsOutput), args.Error(1)
+}
+
+func (m *fakeElb) RegisterInstancesWithLoadBalancer(input *aws_elb.RegisterInstancesWithLoadBalancerInput) (*aws_elb.RegisterInstancesWithLoadBalancerOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*aws_elb.RegisterInstancesWithLoadBalancerOutput), args.Error(1)
+}
+
+func (m *fakeElb) DeregisterInstancesFromLoadBalancer(input *aws_elb.DeregisterInstancesFromLoadBalancerInput) (*aws_elb.DeregisterInstancesFromLoadBalancerOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*aws_elb.DeregisterInstancesFromLoadBalancerOutput), args.Error(1)
+}
+
+type fakeMetadata struct {
+	mock.Mock
+}
+
+func (m *fakeMetadata) Available() bool {
+	args := m.Called()
+	return args.Bool(0)
+}
+
+func (m *fakeMetadata) Region() (string, error) {
+	args := m.Called()
+	return args.String(0), nil
+}
+
+func (m *fakeMetadata) GetInstanceIdentityDocument() (ec2metadata.EC2InstanceIdentityDocument, error) {
+	args := m.Called()
+	return args.Get(0).(ec2metadata.EC2InstanceIdentityDocument), args.Error(1)
+}
+
+func mockLoadBalancers(m *fakeElb, names ...string) {
+	var descriptions []*aws_elb.LoadBalancerDescription
+	for _, name := range names {
+		descriptions = append(descriptions, &aws_elb.LoadBalancerDescription{
+			LoadBalancerName:          aws.String(name),
+			DNSName:                   aws.String("elb-dnsname"),
+			CanonicalHostedZoneNameID: aws.String("test-id"),
+			Scheme:                    aws.String(elbInternalScheme),
+		})
+	}
+	m.On("DescribeLoadBalancers", mock.AnythingOfType("*elb.DescribeLoadBalancersInput")).Return(&aws_elb.DescribeLoadBalancersOutput{
+		LoadBalancerDescriptions: descriptions,
+	}, nil)
+}
+
+func mockClusterTags(m *fakeElb, name string, tags ...*aws_elb.Tag) {
+	m.On("DescribeTags", mock.AnythingOfType("*elb.DescribeTagsInput")).Return(&aws_elb.DescribeTagsOutput{
+		TagDescriptions: []*aws_elb.TagDescription{{LoadBalancerName: aws.String(name), Tags: tags}},
+	}, nil)
+}
+
+func mockInstanceMetadata(mockMd *fakeMetadata, instanceID string) {
+	mockMd.On("GetInstanceIdentityDocument").Return(ec2metadata.EC2InstanceIdentityDocument{InstanceID: instanceID}, nil)
+}
+
+func setup() (controller.Updater, *fakeElb, *fakeMetadata) {
+	e, _ := New(region, clusterName, 1, 0)
+	mockElb := &fakeElb{}
+	mockMetadata := &fakeMetadata{}
+	e.(*classicelb).awsElb = mockElb
+	e.(*classicelb).metadata = mockMetadata
+	return e, mockElb, mockMetadata
+}
+
+func TestCanNotCreateUpdaterWithoutLabelValue(t *testing.T) {
+	// when
+	_, err := New(region, "", 1, 0)
+
+	// then
+	assert.Error(t, err)
+}
+
+func TestAttachesToTaggedLoadBalancer(t *testing.T) {
+	// given
+	e, mockElb, mockMetadata := setup()
+	instanceID := "cow"
+	mockInstanceMetadata(mockMetadata, instanceID)
+	mockLoadBalancers(mockElb, "cluster-frontend")
+	mockClusterTags(mockElb, "cluster-frontend", &aws_elb.Tag{Key: aws.String(frontendTag), Value: aws.String(clusterName)})
+	mockElb.On("RegisterInstancesWithLoadBalancer", &aws_elb.RegisterInstancesWithLoadBalancerInput{
+		LoadBalancerName: aws.String("cluster-frontend"),
+		Instances:        []*aws_elb.Instance{{InstanceId: aws.String(instanceID)}},
+	}).Return(&aws_elb.RegisterInstancesWithLoadBalancerOutput{}, nil)
+
+	// when
+	err := e.Start()
+	updateErr := e.Update(controller.IngressEntries{})
+
+	// then
+	assert.NoError(t, err)
+	assert.NoError(t, updateErr)
+	assert.NoError(t, e.Health())
+	mockElb.AssertExpectations(t)
+}
+
+func TestReportsErrorIfExpectedNotMatched(t *testing.T) {
+	// given
+	e, mockElb, mockMetadata := setup()
+	e.(*classicelb).expectedNumber = 2
+	mockInstanceMetadata(mockMetadata, "cow")
+	mockLoadBalancers(mockElb, "cluster-frontend")
+	mockClusterTags(mockElb, "cluster-frontend", &aws_elb.Tag{Key: aws.String(frontendTag), Value: aws.String(clusterName)})
+
+	// when
+	err := e.Update(controller.IngressEntries{})
+
+	// then
+	assert.EqualError(t, err, "expected ELBs: 2 actual: 1")
+}
+
+func TestDeregistersOnStop(t *testing.T) {
+	// given
+	e, mockElb, mockMetadata := setup()
+	e.(*classicelb).drainDelay = time.Millisecond * 100
+	instanceID := "cow"
+	mockInstanceMetadata(mockMetadata, instanceID)
+	mockLoadBalancers(mockElb, "cluster-frontend")
+	mockClusterTags(mockElb, "cluster-frontend", &aws_elb.Tag{Key: aws.String(frontendTag), Value: aws.String(clusterName)})
+	mockElb.On("RegisterInstancesWithLoadBalancer", mock.Anything).Return(&aws_elb.RegisterInstancesWithLoadBalancerOutput{}, nil)
+	mockElb.On("DeregisterInstancesFromLoadBalancer", &aws_elb.DeregisterInstancesFromLoadBalancerInput{
+		LoadBalancerName: aws.String("cluster-frontend"),
+		Instances:        []*aws_elb.Instance{{InstanceId: aws.String(instanceID)}},
+	}).Return(&aws_elb.DeregisterInstancesFromLoadBalancerOutput{}, nil)
+
+	// when
+	assert.NoError(t, e.Update(controller.IngressEntries{}))
+	beforeStop := time.Now()
+	assert.NoError(t, e.Stop())
+	stopDuration := time.Since(beforeStop)
+
+	// then
+	mockElb.AssertExpectations(t)
+	assert.True(t, stopDuration.Nanoseconds() > time.Millisecond.Nanoseconds()*50,
+		"Drain time should have caused stop to take at least 50ms.")
+}
+
+func TestErrorGettingMetadata(t *testing.T) {
+	e, _, mockMetadata := setup()
+	mockMetadata.On("GetInstanceIdentityDocument").Return(ec2metadata.EC2InstanceIdentityDocument{}, errors.New("no metadata for you"))
+
+	err := e.Update(controller.IngressEntries{})
+
+	assert.EqualError(t, err, "unable to query ec2 metadata service for InstanceId: no metadata for you")
+}
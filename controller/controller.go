@@ -0,0 +1,35 @@
+// Package controller defines the contract between the feed controller and the
+// front end updaters (elb, alb, ...) that attach it to load balancers.
+package controller
+
+// IngressEntry describes a single ingress rule being served by feed.
+type IngressEntry struct {
+	// Name is the namespace/name of the ingress resource.
+	Name string
+	// Host is the virtual host being served.
+	Host string
+	// Path is the path prefix being served.
+	Path string
+	// ServiceAddress is the address of the backend service.
+	ServiceAddress string
+	// ServicePort is the port of the backend service.
+	ServicePort int32
+}
+
+// IngressEntries is a collection of ingress entries known to the controller.
+type IngressEntries []IngressEntry
+
+// Updater is attached to the controller to update a front end whenever ingress
+// entries change, such as an ELB or a local proxy configuration.
+type Updater interface {
+	// Start the updater, called once at startup before any other methods.
+	Start() error
+	// Stop the updater, called once when feed is shutting down.
+	Stop() error
+	// Update is called whenever the ingress entries have changed.
+	Update(IngressEntries) error
+	// Health returns nil if the updater is healthy, or an error describing why not.
+	Health() error
+	// String returns a description of the updater, for logging.
+	String() string
+}
@@ -0,0 +1,611 @@
+// Package elb attaches feed to AWS ELBv2 (ALB/NLB) target groups that are
+// tagged for the cluster, registering this node (or, in ip target-type mode,
+// the ingress pod IPs) as a target so that traffic is routed to it.
+package elb
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+	aws_elb "github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/sky-uk/feed/controller"
+)
+
+// frontendTagKey is the tag key used to mark a load balancer as a front end
+// for clusterName. It's named distinctly from the test file's own
+// frontendTag constant to avoid a package-scope redeclaration.
+const frontendTagKey = "sky.uk/KubernetesClusterFrontend"
+
+// maxTagsPerCall is the maximum number of resource ARNs the DescribeTags API
+// accepts in a single call.
+const maxTagsPerCall = 20
+
+// defaultDNSResolveTimeout and defaultDNSRetryInterval are used when New is
+// called without explicit values.
+const (
+	defaultDNSResolveTimeout = 2 * time.Minute
+	defaultDNSRetryInterval  = 5 * time.Second
+)
+
+// dnsResolver is the subset of net's resolution API this package depends on,
+// to allow stubbing DNS lookups in tests.
+type dnsResolver interface {
+	LookupHost(host string) ([]string, error)
+}
+
+// netDNSResolver resolves hostnames against the real DNS.
+type netDNSResolver struct{}
+
+func (netDNSResolver) LookupHost(host string) ([]string, error) {
+	return net.LookupHost(host)
+}
+
+// TargetType selects what feed registers as a target in the fronting target
+// groups: the host's own EC2 instance, or the local ingress pod IPs.
+type TargetType string
+
+const (
+	// TargetTypeInstance registers the host's EC2 instance ID as a target.
+	// This requires the target group to be instance-typed.
+	TargetTypeInstance TargetType = "instance"
+	// TargetTypeIP registers the ingress pod IPs as targets. This requires
+	// the target group to be ip-typed, and is the mode to use with the AWS
+	// VPC CNI where pod IPs are routable.
+	TargetTypeIP TargetType = "ip"
+)
+
+// awsElb is the subset of the elbv2 API that this package depends on.
+type awsElb interface {
+	DescribeLoadBalancers(input *aws_elb.DescribeLoadBalancersInput) (*aws_elb.DescribeLoadBalancersOutput, error)
+	DescribeTags(input *aws_elb.DescribeTagsInput) (*aws_elb.DescribeTagsOutput, error)
+	DescribeTargetGroups(input *aws_elb.DescribeTargetGroupsInput) (*aws_elb.DescribeTargetGroupsOutput, error)
+	DescribeListeners(input *aws_elb.DescribeListenersInput) (*aws_elb.DescribeListenersOutput, error)
+	CreateTargetGroup(input *aws_elb.CreateTargetGroupInput) (*aws_elb.CreateTargetGroupOutput, error)
+	CreateListener(input *aws_elb.CreateListenerInput) (*aws_elb.CreateListenerOutput, error)
+	RegisterTargets(input *aws_elb.RegisterTargetsInput) (*aws_elb.RegisterTargetsOutput, error)
+	DeregisterTargets(input *aws_elb.DeregisterTargetsInput) (*aws_elb.DeregisterTargetsOutput, error)
+}
+
+// metadata is the subset of the ec2metadata API that this package depends on.
+type metadata interface {
+	Available() bool
+	Region() (string, error)
+	GetInstanceIdentityDocument() (ec2metadata.EC2InstanceIdentityDocument, error)
+}
+
+// PodIPFinder discovers the local ingress pod IPs that should be registered
+// as targets when running with TargetTypeIP.
+type PodIPFinder interface {
+	FindIngressPodIPs() ([]string, error)
+}
+
+// StaticPodIPFinder is a PodIPFinder that always returns a fixed set of IPs.
+// It's used when feed already knows its own pod IP, such as being passed it
+// through the downward API, rather than having to query the Kubernetes API
+// with a label selector.
+type StaticPodIPFinder []string
+
+// FindIngressPodIPs returns the configured static IPs.
+func (s StaticPodIPFinder) FindIngressPodIPs() ([]string, error) {
+	return []string(s), nil
+}
+
+// LoadBalancerDetails describes a front end load balancer tagged for this
+// cluster.
+type LoadBalancerDetails struct {
+	Name         string
+	DNSName      string
+	HostedZoneID string
+	Scheme       string
+	ARN          string
+}
+
+type registeredFrontend struct {
+	name           string
+	targetGroupArn string
+	targetIDs      []string
+}
+
+type elb struct {
+	awsElb            awsElb
+	metadata          metadata
+	clusterName       string
+	expectedNumber    int
+	drainDelay        time.Duration
+	targetType        TargetType
+	podIPFinder       PodIPFinder
+	nlbListeners      []NLBListenerSpec
+	vpcID             string
+	dnsResolver       dnsResolver
+	dnsResolveTimeout time.Duration
+	dnsRetryInterval  time.Duration
+	tagCache          *tagCache
+
+	mu                   sync.Mutex
+	registered           []registeredFrontend
+	registeredNLBTargets []registeredFrontend
+	readinessErr         error
+	nlbReconcileErrs     map[string]error
+	pendingDNS           map[string]bool
+	dnsWaitGen           int
+	dnsWaitStartedAt     time.Time
+}
+
+// New creates an updater that attaches feed to the ELBv2 target groups
+// tagged sky.uk/KubernetesClusterFrontend for clusterFrontEndNameValue.
+//
+// targetType selects what is registered in each target group: the host's EC2
+// instance (TargetTypeInstance, the default), or the ingress pod IPs
+// discovered through podIPFinder (TargetTypeIP). podIPFinder may be nil when
+// targetType is TargetTypeInstance.
+//
+// nlbListeners declares listeners/target groups that should be reconciled
+// onto every NLB (Type=network) tagged for the cluster, in addition to the
+// pre-existing ALB/NLB target groups attached above. It may be empty if the
+// cluster isn't fronted by any auto-provisioned NLBs. vpcID is the VPC to
+// create those target groups in, and is required when nlbListeners is
+// non-empty.
+//
+// dnsResolveTimeout bounds how long Health() reports plain "waiting for DNS"
+// for a newly attached load balancer before its DNS name resolves; past that
+// it escalates to "still waiting for DNS after ..." instead of giving up, since
+// AWS gives no hard guarantee on how long propagation takes. dnsRetryInterval
+// is how often resolution is retried in the meantime. Both default to 2
+// minutes and 5 seconds respectively when zero.
+//
+// tagCacheTTL bounds how long a load balancer's tags are cached between
+// DescribeTags calls, to avoid re-querying every known ARN's tags on every
+// Update and running into AWS throttling in accounts with many load
+// balancers. It defaults to 5 minutes when zero.
+func New(region string, clusterFrontEndNameValue string, expectedNumber int, drainDelay time.Duration,
+	targetType TargetType, podIPFinder PodIPFinder, nlbListeners []NLBListenerSpec, vpcID string,
+	dnsResolveTimeout time.Duration, dnsRetryInterval time.Duration, tagCacheTTL time.Duration) (controller.Updater, error) {
+	if clusterFrontEndNameValue == "" {
+		return nil, errors.New("unable to create ELB updater: cluster name must not be blank")
+	}
+
+	if targetType == "" {
+		targetType = TargetTypeInstance
+	}
+	if targetType == TargetTypeIP && podIPFinder == nil {
+		return nil, errors.New("unable to create ELB updater: pod IP finder must be set for ip target type")
+	}
+	if len(nlbListeners) > 0 && vpcID == "" {
+		return nil, errors.New("unable to create ELB updater: vpc id must be set to reconcile nlb listeners")
+	}
+	if dnsResolveTimeout == 0 {
+		dnsResolveTimeout = defaultDNSResolveTimeout
+	}
+	if dnsRetryInterval == 0 {
+		dnsRetryInterval = defaultDNSRetryInterval
+	}
+	registerTagCacheMetrics()
+
+	awsSession, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create ELB updater: %v", err)
+	}
+
+	return &elb{
+		awsElb:            aws_elb.New(awsSession),
+		metadata:          ec2metadata.New(awsSession),
+		clusterName:       clusterFrontEndNameValue,
+		expectedNumber:    expectedNumber,
+		drainDelay:        drainDelay,
+		targetType:        targetType,
+		podIPFinder:       podIPFinder,
+		nlbListeners:      nlbListeners,
+		vpcID:             vpcID,
+		dnsResolver:       netDNSResolver{},
+		dnsResolveTimeout: dnsResolveTimeout,
+		dnsRetryInterval:  dnsRetryInterval,
+		tagCache:          newTagCache(tagCacheTTL),
+	}, nil
+}
+
+func (e *elb) Start() error {
+	return nil
+}
+
+func (e *elb) Stop() error {
+	e.mu.Lock()
+	registered := e.registered
+	registeredNLBTargets := e.registeredNLBTargets
+	e.mu.Unlock()
+
+	failed := e.deregister(registered)
+	if e.deregister(registeredNLBTargets) {
+		failed = true
+	}
+
+	time.Sleep(e.drainDelay)
+
+	if failed {
+		return errors.New("at least one ELB failed to detach")
+	}
+	return nil
+}
+
+// deregister deregisters each frontend's targetIDs from its target group,
+// returning true if at least one deregistration failed.
+func (e *elb) deregister(frontends []registeredFrontend) bool {
+	var failed bool
+	for _, frontend := range frontends {
+		_, err := e.awsElb.DeregisterTargets(&aws_elb.DeregisterTargetsInput{
+			TargetGroupArn: aws.String(frontend.targetGroupArn),
+			Targets:        targetDescriptions(frontend.targetIDs, nil),
+		})
+		if err != nil {
+			failed = true
+		}
+	}
+	return failed
+}
+
+func (e *elb) Update(controller.IngressEntries) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	targetIDs, err := e.registrationTargets()
+	if err != nil {
+		e.readinessErr = err
+		return err
+	}
+
+	lbs, tags, err := e.describeTaggedLoadBalancersCached()
+	if err != nil {
+		e.readinessErr = err
+		return err
+	}
+	frontends := frontEndsFromTaggedLoadBalancers(lbs, tags, e.clusterName)
+
+	if len(frontends) != e.expectedNumber {
+		e.readinessErr = fmt.Errorf("expected ELBs: %d actual: %d", e.expectedNumber, len(frontends))
+		return e.readinessErr
+	}
+
+	ports, err := e.registrationPorts(frontends)
+	if err != nil {
+		e.readinessErr = err
+		return err
+	}
+
+	var registered []registeredFrontend
+	for _, frontend := range frontends {
+		_, err = e.awsElb.RegisterTargets(&aws_elb.RegisterTargetsInput{
+			TargetGroupArn: aws.String(frontend.ARN),
+			Targets:        targetDescriptions(targetIDs, ports[frontend.ARN]),
+		})
+		if err != nil {
+			e.readinessErr = fmt.Errorf("unable to register %s %s with elb %s: %v", e.targetNoun(), firstOrEmpty(targetIDs), frontend.Name, err)
+			return e.readinessErr
+		}
+		registered = append(registered, registeredFrontend{
+			name:           frontend.Name,
+			targetGroupArn: frontend.ARN,
+			targetIDs:      targetIDs,
+		})
+	}
+
+	e.registered = registered
+	e.readinessErr = nil
+	e.registeredNLBTargets, e.nlbReconcileErrs = e.reconcileNLBs(targetIDs, lbs, tags)
+	e.startDNSWait(frontends)
+	return nil
+}
+
+// startDNSWait checks each attached frontend's DNS name and, for any that
+// don't yet resolve, keeps retrying in the background so that Health()
+// reports "waiting for DNS" until AWS has finished propagating it. Called
+// with e.mu held, by Update().
+func (e *elb) startDNSWait(frontends map[string]*LoadBalancerDetails) {
+	names := make([]string, 0, len(frontends))
+	for _, frontend := range frontends {
+		names = append(names, frontend.DNSName)
+	}
+
+	pending := e.resolveDNS(names)
+	e.pendingDNS = pending
+	e.dnsWaitGen++
+	if len(pending) == 0 {
+		return
+	}
+
+	e.dnsWaitStartedAt = time.Now()
+	go e.waitForDNS(e.dnsWaitGen, names)
+}
+
+// waitForDNS retries resolving names at e.dnsRetryInterval, updating
+// e.pendingDNS as it goes, until they all resolve or a later Update()
+// supersedes it (gen no longer matches e.dnsWaitGen). It never gives up on
+// its own: AWS gives no hard guarantee on DNS propagation time, and Update()
+// only runs again when the ingress entries change (controller.Updater), not
+// on a timer, so abandoning the retry here would leave Health() permanently
+// stuck reporting "waiting for DNS" with nothing left to re-arm it.
+// dnsResolveTimeout instead bounds how long Health()'s message stays
+// "waiting for DNS" before escalating to "still waiting for DNS after ...".
+func (e *elb) waitForDNS(gen int, names []string) {
+	for {
+		time.Sleep(e.dnsRetryInterval)
+
+		pending := e.resolveDNS(names)
+
+		e.mu.Lock()
+		if e.dnsWaitGen != gen {
+			e.mu.Unlock()
+			return
+		}
+		e.pendingDNS = pending
+		e.mu.Unlock()
+
+		if len(pending) == 0 {
+			return
+		}
+	}
+}
+
+// resolveDNS returns the subset of names that don't currently resolve to at
+// least one address.
+func (e *elb) resolveDNS(names []string) map[string]bool {
+	pending := make(map[string]bool)
+	for _, name := range names {
+		ips, err := e.dnsResolver.LookupHost(name)
+		if err != nil || len(ips) == 0 {
+			pending[name] = true
+		}
+	}
+	return pending
+}
+
+// reconcileNLBs reconciles e.nlbListeners onto every NLB tagged for the
+// cluster within lbs/tags (already fetched by Update(), so this doesn't
+// re-query DescribeLoadBalancers/DescribeTags), returning the target groups
+// successfully registered against (so Stop() can deregister them) and any
+// reconciliation errors keyed by NLB ARN. It's a no-op when no NLB listeners
+// are declared.
+func (e *elb) reconcileNLBs(targetIDs []string, lbs []*aws_elb.LoadBalancer, tags []*aws_elb.TagDescription) ([]registeredFrontend, map[string]error) {
+	if len(e.nlbListeners) == 0 {
+		return nil, nil
+	}
+
+	nlbs := networkLoadBalancersFromTagged(lbs, tags, e.clusterName)
+	return reconcileNLBs(e.awsElb, nlbs, e.nlbListeners, e.targetType, targetIDs, e.vpcID)
+}
+
+// registrationPorts returns the port to register targets on for each front
+// end's target group, keyed by target group ARN. For TargetTypeInstance this
+// is always nil, since the target registers on its own port. For TargetTypeIP
+// it verifies every target group is ip-typed in a single batched
+// DescribeTargetGroups call and returns each group's configured port.
+func (e *elb) registrationPorts(frontends map[string]*LoadBalancerDetails) (map[string]*int64, error) {
+	ports := make(map[string]*int64, len(frontends))
+	if e.targetType != TargetTypeIP {
+		for _, frontend := range frontends {
+			ports[frontend.ARN] = nil
+		}
+		return ports, nil
+	}
+
+	arns := make([]*string, 0, len(frontends))
+	for _, frontend := range frontends {
+		arns = append(arns, aws.String(frontend.ARN))
+	}
+
+	groups, err := describeTargetGroupsByArn(e.awsElb, arns)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range groups {
+		if aws.StringValue(group.TargetType) != string(TargetTypeIP) {
+			return nil, fmt.Errorf("target group %s is not ip-typed, got %s", aws.StringValue(group.TargetGroupArn), aws.StringValue(group.TargetType))
+		}
+		ports[aws.StringValue(group.TargetGroupArn)] = group.Port
+	}
+
+	return ports, nil
+}
+
+func (e *elb) Health() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.readinessErr != nil {
+		return e.readinessErr
+	}
+	if len(e.nlbReconcileErrs) > 0 {
+		return fmt.Errorf("%d NLB(s) failed to reconcile: %v", len(e.nlbReconcileErrs), e.nlbReconcileErrs)
+	}
+	for name := range e.pendingDNS {
+		if time.Since(e.dnsWaitStartedAt) > e.dnsResolveTimeout {
+			return fmt.Errorf("still waiting for DNS after %s: %s", e.dnsResolveTimeout, name)
+		}
+		return fmt.Errorf("waiting for DNS: %s", name)
+	}
+	return nil
+}
+
+func (e *elb) String() string {
+	return "elb frontend"
+}
+
+// targetNoun names what's being registered, for error messages: "instance"
+// for TargetTypeInstance, "pod IP" for TargetTypeIP.
+func (e *elb) targetNoun() string {
+	if e.targetType == TargetTypeIP {
+		return "pod IP"
+	}
+	return "instance"
+}
+
+// registrationTargets returns the target IDs to register in each front end
+// target group: the ingress pod IPs for TargetTypeIP, or this host's EC2
+// instance ID for TargetTypeInstance.
+func (e *elb) registrationTargets() ([]string, error) {
+	if e.targetType == TargetTypeIP {
+		ips, err := e.podIPFinder.FindIngressPodIPs()
+		if err != nil {
+			return nil, fmt.Errorf("unable to determine ingress pod IPs: %v", err)
+		}
+		if len(ips) == 0 {
+			return nil, errors.New("no ingress pod IPs found to register")
+		}
+		return ips, nil
+	}
+
+	doc, err := e.metadata.GetInstanceIdentityDocument()
+	if err != nil {
+		return nil, fmt.Errorf("unable to query ec2 metadata service for InstanceId: %v", err)
+	}
+	return []string{doc.InstanceID}, nil
+}
+
+func targetDescriptions(ids []string, port *int64) []*aws_elb.TargetDescription {
+	descriptions := make([]*aws_elb.TargetDescription, 0, len(ids))
+	for _, id := range ids {
+		descriptions = append(descriptions, &aws_elb.TargetDescription{Id: aws.String(id), Port: port})
+	}
+	return descriptions
+}
+
+func firstOrEmpty(ids []string) string {
+	if len(ids) == 0 {
+		return ""
+	}
+	return ids[0]
+}
+
+// describeTargetGroupsByArn fetches target group details for the given ARNs
+// in a single batched call, used to verify TargetType before registering pod
+// IPs against a target group.
+func describeTargetGroupsByArn(e awsElb, arns []*string) ([]*aws_elb.TargetGroup, error) {
+	if len(arns) == 0 {
+		return nil, nil
+	}
+	output, err := e.DescribeTargetGroups(&aws_elb.DescribeTargetGroupsInput{TargetGroupArns: arns})
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe target groups: %v", err)
+	}
+	return output.TargetGroups, nil
+}
+
+// FindFrontEndElbs finds the ELBv2 load balancers tagged
+// sky.uk/KubernetesClusterFrontend for clusterName, keyed by scheme.
+func FindFrontEndElbs(e awsElb, clusterName string) (map[string]*LoadBalancerDetails, error) {
+	lbs, tags, err := describeTaggedLoadBalancers(e)
+	if err != nil {
+		return nil, err
+	}
+	return frontEndsFromTaggedLoadBalancers(lbs, tags, clusterName), nil
+}
+
+// describeTaggedLoadBalancers fetches every load balancer and its tags in one
+// paginated/batched pass, so that callers needing both the ALB/NLB frontends
+// and the tagged NLBs within the same cycle (see elb.Update) can share a
+// single fetch instead of querying the elbv2 API twice.
+func describeTaggedLoadBalancers(e awsElb) ([]*aws_elb.LoadBalancer, []*aws_elb.TagDescription, error) {
+	lbs, err := describeLoadBalancers(e)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to describe load balancers: %v", err)
+	}
+
+	tags, err := describeTags(e, lbs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to describe tags: %v", err)
+	}
+
+	return lbs, tags, nil
+}
+
+// describeTaggedLoadBalancersCached is describeTaggedLoadBalancers, but
+// serving tags from e.tagCache where possible instead of querying
+// DescribeTags for every known ARN on every call. Used by Update, which runs
+// on every reconcile; FindFrontEndElbs is used standalone and always fetches
+// fresh tags.
+func (e *elb) describeTaggedLoadBalancersCached() ([]*aws_elb.LoadBalancer, []*aws_elb.TagDescription, error) {
+	lbs, err := describeLoadBalancers(e.awsElb)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to describe load balancers: %v", err)
+	}
+	e.tagCache.prune(lbs)
+
+	tags, err := e.tagCache.describeTagsCached(e.awsElb, lbs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to describe tags: %v", err)
+	}
+
+	return lbs, tags, nil
+}
+
+func frontEndsFromTaggedLoadBalancers(lbs []*aws_elb.LoadBalancer, tags []*aws_elb.TagDescription, clusterName string) map[string]*LoadBalancerDetails {
+	frontends := make(map[string]*LoadBalancerDetails)
+	for i, l := range lbs {
+		if aws.StringValue(l.Type) == networkLoadBalancerType {
+			continue
+		}
+		if i >= len(tags) || !hasClusterTag(tags[i].Tags, clusterName) {
+			continue
+		}
+		frontends[aws.StringValue(l.Scheme)] = &LoadBalancerDetails{
+			Name:         aws.StringValue(l.LoadBalancerName),
+			DNSName:      aws.StringValue(l.DNSName),
+			HostedZoneID: aws.StringValue(l.CanonicalHostedZoneId),
+			Scheme:       aws.StringValue(l.Scheme),
+			ARN:          aws.StringValue(l.LoadBalancerArn),
+		}
+	}
+
+	return frontends
+}
+
+func describeLoadBalancers(e awsElb) ([]*aws_elb.LoadBalancer, error) {
+	var all []*aws_elb.LoadBalancer
+	input := &aws_elb.DescribeLoadBalancersInput{}
+	for {
+		output, err := e.DescribeLoadBalancers(input)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, output.LoadBalancers...)
+		if output.NextMarker == nil {
+			return all, nil
+		}
+		input = &aws_elb.DescribeLoadBalancersInput{Marker: output.NextMarker}
+	}
+}
+
+func describeTags(e awsElb, lbs []*aws_elb.LoadBalancer) ([]*aws_elb.TagDescription, error) {
+	var all []*aws_elb.TagDescription
+	for start := 0; start < len(lbs); start += maxTagsPerCall {
+		end := start + maxTagsPerCall
+		if end > len(lbs) {
+			end = len(lbs)
+		}
+
+		arns := make([]*string, 0, end-start)
+		for _, l := range lbs[start:end] {
+			arns = append(arns, l.LoadBalancerArn)
+		}
+
+		output, err := e.DescribeTags(&aws_elb.DescribeTagsInput{ResourceArns: arns})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, output.TagDescriptions...)
+	}
+	return all, nil
+}
+
+func hasClusterTag(tags []*aws_elb.Tag, clusterName string) bool {
+	for _, t := range tags {
+		if aws.StringValue(t.Key) == frontendTagKey && aws.StringValue(t.Value) == clusterName {
+			return true
+		}
+	}
+	return false
+}
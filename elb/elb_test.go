@@ -55,6 +55,41 @@ func (m *fakeElb) RegisterTargets(input *aws_elb.RegisterTargetsInput) (*aws_elb
 	return args.Get(0).(*aws_elb.RegisterTargetsOutput), args.Error(1)
 }
 
+func (m *fakeElb) DescribeTargetGroups(input *aws_elb.DescribeTargetGroupsInput) (*aws_elb.DescribeTargetGroupsOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*aws_elb.DescribeTargetGroupsOutput), args.Error(1)
+}
+
+func (m *fakeElb) DescribeListeners(input *aws_elb.DescribeListenersInput) (*aws_elb.DescribeListenersOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*aws_elb.DescribeListenersOutput), args.Error(1)
+}
+
+func (m *fakeElb) CreateTargetGroup(input *aws_elb.CreateTargetGroupInput) (*aws_elb.CreateTargetGroupOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*aws_elb.CreateTargetGroupOutput), args.Error(1)
+}
+
+func (m *fakeElb) CreateListener(input *aws_elb.CreateListenerInput) (*aws_elb.CreateListenerOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*aws_elb.CreateListenerOutput), args.Error(1)
+}
+
+type fakeDNSResolver struct {
+	mock.Mock
+}
+
+func (m *fakeDNSResolver) LookupHost(host string) ([]string, error) {
+	args := m.Called(host)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// stubResolvedDNS makes every LookupHost call resolve immediately, for tests
+// that aren't exercising the DNS readiness gate itself.
+func stubResolvedDNS(m *fakeDNSResolver) {
+	m.On("LookupHost", mock.Anything).Return([]string{"10.0.0.1"}, nil)
+}
+
 type fakeMetadata struct {
 	mock.Mock
 }
@@ -129,17 +164,40 @@ func mockInstanceMetadata(mockMd *fakeMetadata, instanceID string) {
 }
 
 func setup() (controller.Updater, *fakeElb, *fakeMetadata) {
-	e, _ := New(region, clusterName, 1, 0)
+	e, _ := New(region, clusterName, 1, 0, TargetTypeInstance, nil, nil, "", time.Millisecond*50, time.Millisecond*10, 0)
+	mockElb := &fakeElb{}
+	mockMetadata := &fakeMetadata{}
+	mockDNS := &fakeDNSResolver{}
+	stubResolvedDNS(mockDNS)
+	e.(*elb).awsElb = mockElb
+	e.(*elb).metadata = mockMetadata
+	e.(*elb).dnsResolver = mockDNS
+	return e, mockElb, mockMetadata
+}
+
+func setupIPTarget(podIPs ...string) (controller.Updater, *fakeElb, *fakeMetadata) {
+	e, _ := New(region, clusterName, 1, 0, TargetTypeIP, StaticPodIPFinder(podIPs), nil, "", time.Millisecond*50, time.Millisecond*10, 0)
 	mockElb := &fakeElb{}
 	mockMetadata := &fakeMetadata{}
+	mockDNS := &fakeDNSResolver{}
+	stubResolvedDNS(mockDNS)
 	e.(*elb).awsElb = mockElb
 	e.(*elb).metadata = mockMetadata
+	e.(*elb).dnsResolver = mockDNS
 	return e, mockElb, mockMetadata
 }
 
 func TestCanNotCreateUpdaterWithoutLabelValue(t *testing.T) {
 	//when
-	_, err := New(region, "", 1, 0)
+	_, err := New(region, "", 1, 0, TargetTypeInstance, nil, nil, "", 0, 0, 0)
+
+	//then
+	assert.Error(t, err)
+}
+
+func TestCanNotCreateIPTargetUpdaterWithoutPodIPFinder(t *testing.T) {
+	//when
+	_, err := New(region, clusterName, 1, 0, TargetTypeIP, nil, nil, "", 0, 0, 0)
 
 	//then
 	assert.Error(t, err)
@@ -360,6 +418,52 @@ func TestTagCallsPage(t *testing.T) {
 	mockElb.AssertExpectations(t)
 }
 
+func TestTagCacheAvoidsRedescribingUnchangedLoadBalancers(t *testing.T) {
+	// given
+	e, mockElb, mockMetadata := setup()
+	instanceID := "cow"
+	clusterFrontEnd := "cluster-frontend"
+	arn := "lb-arn"
+	mockInstanceMetadata(mockMetadata, instanceID)
+	mockLoadBalancers(mockElb, lb{name: clusterFrontEnd, scheme: elbInternalScheme, arn: arn})
+	mockClusterTags(mockElb,
+		lbTags{name: clusterFrontEnd, tags: []*aws_elb.Tag{{Key: aws.String(frontendTag), Value: aws.String(clusterName)}}})
+	mockRegisterTargets(mockElb, arn, instanceID)
+
+	// when
+	firstErr := e.Update(controller.IngressEntries{})
+	secondErr := e.Update(controller.IngressEntries{})
+
+	// then
+	assert.NoError(t, firstErr)
+	assert.NoError(t, secondErr)
+	mockElb.AssertNumberOfCalls(t, "DescribeTags", 1)
+}
+
+func TestTagCacheRefetchesOnceTTLExpires(t *testing.T) {
+	// given
+	e, mockElb, mockMetadata := setup()
+	e.(*elb).tagCache = newTagCache(time.Millisecond * 10)
+	instanceID := "cow"
+	clusterFrontEnd := "cluster-frontend"
+	arn := "lb-arn"
+	mockInstanceMetadata(mockMetadata, instanceID)
+	mockLoadBalancers(mockElb, lb{name: clusterFrontEnd, scheme: elbInternalScheme, arn: arn})
+	mockClusterTags(mockElb,
+		lbTags{name: clusterFrontEnd, tags: []*aws_elb.Tag{{Key: aws.String(frontendTag), Value: aws.String(clusterName)}}})
+	mockRegisterTargets(mockElb, arn, instanceID)
+
+	// when
+	firstErr := e.Update(controller.IngressEntries{})
+	time.Sleep(time.Millisecond * 20)
+	secondErr := e.Update(controller.IngressEntries{})
+
+	// then
+	assert.NoError(t, firstErr)
+	assert.NoError(t, secondErr)
+	mockElb.AssertNumberOfCalls(t, "DescribeTags", 2)
+}
+
 func TestDeregistersWithAttachedELBs(t *testing.T) {
 	// given
 	e, mockElb, mockMetadata := setup()
@@ -479,6 +583,55 @@ func TestRetriesUpdateIfFirstAttemptFails(t *testing.T) {
 	assert.Error(t, secondErr)
 }
 
+func TestAttachesPodIPsWhenTargetTypeIsIP(t *testing.T) {
+	// given
+	podIP := "10.0.0.1"
+	e, mockElb, _ := setupIPTarget(podIP)
+	clusterFrontEnd := "cluster-frontend"
+	arn := "lb-arn"
+	port := int64(8080)
+	mockLoadBalancers(mockElb, lb{name: clusterFrontEnd, scheme: elbInternalScheme, arn: arn})
+	mockClusterTags(mockElb,
+		lbTags{name: clusterFrontEnd, tags: []*aws_elb.Tag{{Key: aws.String(frontendTag), Value: aws.String(clusterName)}}})
+	mockElb.On("DescribeTargetGroups", &aws_elb.DescribeTargetGroupsInput{TargetGroupArns: []*string{aws.String(arn)}}).
+		Return(&aws_elb.DescribeTargetGroupsOutput{
+			TargetGroups: []*aws_elb.TargetGroup{{TargetGroupArn: aws.String(arn), TargetType: aws.String("ip"), Port: aws.Int64(port)}},
+		}, nil)
+	mockElb.On("RegisterTargets", &aws_elb.RegisterTargetsInput{
+		TargetGroupArn: aws.String(arn),
+		Targets:        []*aws_elb.TargetDescription{{Id: aws.String(podIP), Port: aws.Int64(port)}},
+	}).Return(&aws_elb.RegisterTargetsOutput{}, nil)
+
+	// when
+	err := e.Start()
+	updateErr := e.Update(controller.IngressEntries{})
+
+	// then
+	assert.NoError(t, err)
+	assert.NoError(t, updateErr)
+	mockElb.AssertExpectations(t)
+}
+
+func TestRejectsInstanceTypedTargetGroupWhenTargetTypeIsIP(t *testing.T) {
+	// given
+	e, mockElb, _ := setupIPTarget("10.0.0.1")
+	clusterFrontEnd := "cluster-frontend"
+	arn := "lb-arn"
+	mockLoadBalancers(mockElb, lb{name: clusterFrontEnd, scheme: elbInternalScheme, arn: arn})
+	mockClusterTags(mockElb,
+		lbTags{name: clusterFrontEnd, tags: []*aws_elb.Tag{{Key: aws.String(frontendTag), Value: aws.String(clusterName)}}})
+	mockElb.On("DescribeTargetGroups", &aws_elb.DescribeTargetGroupsInput{TargetGroupArns: []*string{aws.String(arn)}}).
+		Return(&aws_elb.DescribeTargetGroupsOutput{
+			TargetGroups: []*aws_elb.TargetGroup{{TargetType: aws.String("instance")}},
+		}, nil)
+
+	// when
+	err := e.Update(controller.IngressEntries{})
+
+	// then
+	assert.Error(t, err)
+}
+
 func TestHealthReportsHealthyBeforeFirstUpdate(t *testing.T) {
 	// given
 	e, _, _ := setup()
@@ -516,3 +669,264 @@ func TestHealthReportsUnhealthyAfterUnsuccessfulFirstUpdate(t *testing.T) {
 	assert.Error(t, updateErr)
 	assert.Error(t, e.Health())
 }
+
+func setupNLB(nlbListeners ...NLBListenerSpec) (controller.Updater, *fakeElb, *fakeMetadata) {
+	e, _ := New(region, clusterName, 0, 0, TargetTypeInstance, nil, nlbListeners, "vpc-1234", time.Millisecond*50, time.Millisecond*10, 0)
+	mockElb := &fakeElb{}
+	mockMetadata := &fakeMetadata{}
+	mockDNS := &fakeDNSResolver{}
+	stubResolvedDNS(mockDNS)
+	e.(*elb).dnsResolver = mockDNS
+	e.(*elb).awsElb = mockElb
+	e.(*elb).metadata = mockMetadata
+	return e, mockElb, mockMetadata
+}
+
+func mockTaggedNLB(mockElb *fakeElb, name, arn string) {
+	mockElb.On("DescribeLoadBalancers", mock.AnythingOfType("*elbv2.DescribeLoadBalancersInput")).Return(&aws_elb.DescribeLoadBalancersOutput{
+		LoadBalancers: []*aws_elb.LoadBalancer{{
+			LoadBalancerName: aws.String(name),
+			LoadBalancerArn:  aws.String(arn),
+			Type:             aws.String("network"),
+		}},
+	}, nil)
+	mockElb.On("DescribeTags", mock.AnythingOfType("*elbv2.DescribeTagsInput")).Return(&aws_elb.DescribeTagsOutput{
+		TagDescriptions: []*aws_elb.TagDescription{{
+			Tags: []*aws_elb.Tag{{Key: aws.String(frontendTag), Value: aws.String(clusterName)}},
+		}},
+	}, nil)
+}
+
+func TestReconcilesNLBListenerCreatingMissingTargetGroupAndListener(t *testing.T) {
+	// given
+	spec := NLBListenerSpec{Protocol: "TCP", ListenPort: 22, TargetPort: 32022, HealthCheck: NLBHealthCheck{Protocol: "TCP", Port: 32022}}
+	e, mockElb, mockMetadata := setupNLB(spec)
+	instanceID := "cow"
+	mockInstanceMetadata(mockMetadata, instanceID)
+	nlbArn := "nlb-arn"
+	mockTaggedNLB(mockElb, "nlb-frontend", nlbArn)
+
+	targetGroupArn := "nlb-tg-arn"
+	mockElb.On("DescribeTargetGroups", &aws_elb.DescribeTargetGroupsInput{LoadBalancerArn: aws.String(nlbArn)}).
+		Return(&aws_elb.DescribeTargetGroupsOutput{}, nil)
+	mockElb.On("CreateTargetGroup", &aws_elb.CreateTargetGroupInput{
+		Name:                aws.String("nlb-frontend-tcp-32022"),
+		Protocol:            aws.String("TCP"),
+		Port:                aws.Int64(32022),
+		VpcId:               aws.String("vpc-1234"),
+		TargetType:          aws.String(string(TargetTypeInstance)),
+		HealthCheckProtocol: aws.String("TCP"),
+		HealthCheckPort:     aws.String("32022"),
+	}).Return(&aws_elb.CreateTargetGroupOutput{TargetGroups: []*aws_elb.TargetGroup{{TargetGroupArn: aws.String(targetGroupArn)}}}, nil)
+	mockElb.On("DescribeListeners", &aws_elb.DescribeListenersInput{LoadBalancerArn: aws.String(nlbArn)}).
+		Return(&aws_elb.DescribeListenersOutput{}, nil)
+	mockElb.On("CreateListener", mock.AnythingOfType("*elbv2.CreateListenerInput")).
+		Return(&aws_elb.CreateListenerOutput{}, nil)
+	mockElb.On("RegisterTargets", &aws_elb.RegisterTargetsInput{
+		TargetGroupArn: aws.String(targetGroupArn),
+		Targets:        []*aws_elb.TargetDescription{{Id: aws.String(instanceID)}},
+	}).Return(&aws_elb.RegisterTargetsOutput{}, nil)
+
+	// when
+	err := e.Update(controller.IngressEntries{})
+
+	// then
+	assert.NoError(t, err)
+	assert.NoError(t, e.Health())
+	mockElb.AssertExpectations(t)
+}
+
+func TestReconcilesNLBTargetGroupWithTrafficPortHealthCheckByDefault(t *testing.T) {
+	// given
+	spec := NLBListenerSpec{Protocol: "TCP", ListenPort: 22, TargetPort: 32022, HealthCheck: NLBHealthCheck{Protocol: "TCP"}}
+	e, mockElb, mockMetadata := setupNLB(spec)
+	instanceID := "cow"
+	mockInstanceMetadata(mockMetadata, instanceID)
+	nlbArn := "nlb-arn"
+	mockTaggedNLB(mockElb, "nlb-frontend", nlbArn)
+
+	targetGroupArn := "nlb-tg-arn"
+	mockElb.On("DescribeTargetGroups", &aws_elb.DescribeTargetGroupsInput{LoadBalancerArn: aws.String(nlbArn)}).
+		Return(&aws_elb.DescribeTargetGroupsOutput{}, nil)
+	mockElb.On("CreateTargetGroup", &aws_elb.CreateTargetGroupInput{
+		Name:                aws.String("nlb-frontend-tcp-32022"),
+		Protocol:            aws.String("TCP"),
+		Port:                aws.Int64(32022),
+		VpcId:               aws.String("vpc-1234"),
+		TargetType:          aws.String(string(TargetTypeInstance)),
+		HealthCheckProtocol: aws.String("TCP"),
+		HealthCheckPort:     aws.String("traffic-port"),
+	}).Return(&aws_elb.CreateTargetGroupOutput{TargetGroups: []*aws_elb.TargetGroup{{TargetGroupArn: aws.String(targetGroupArn)}}}, nil)
+	mockElb.On("DescribeListeners", &aws_elb.DescribeListenersInput{LoadBalancerArn: aws.String(nlbArn)}).
+		Return(&aws_elb.DescribeListenersOutput{}, nil)
+	mockElb.On("CreateListener", mock.AnythingOfType("*elbv2.CreateListenerInput")).
+		Return(&aws_elb.CreateListenerOutput{}, nil)
+	mockElb.On("RegisterTargets", &aws_elb.RegisterTargetsInput{
+		TargetGroupArn: aws.String(targetGroupArn),
+		Targets:        []*aws_elb.TargetDescription{{Id: aws.String(instanceID)}},
+	}).Return(&aws_elb.RegisterTargetsOutput{}, nil)
+
+	// when
+	err := e.Update(controller.IngressEntries{})
+
+	// then
+	assert.NoError(t, err)
+	assert.NoError(t, e.Health())
+	mockElb.AssertExpectations(t)
+}
+
+func TestDeregistersNLBTargetsOnStop(t *testing.T) {
+	// given
+	spec := NLBListenerSpec{Protocol: "TCP", ListenPort: 22, TargetPort: 32022}
+	e, mockElb, mockMetadata := setupNLB(spec)
+	instanceID := "cow"
+	mockInstanceMetadata(mockMetadata, instanceID)
+	nlbArn := "nlb-arn"
+	mockTaggedNLB(mockElb, "nlb-frontend", nlbArn)
+
+	targetGroupArn := "nlb-tg-arn"
+	mockElb.On("DescribeTargetGroups", &aws_elb.DescribeTargetGroupsInput{LoadBalancerArn: aws.String(nlbArn)}).
+		Return(&aws_elb.DescribeTargetGroupsOutput{}, nil)
+	mockElb.On("CreateTargetGroup", mock.AnythingOfType("*elbv2.CreateTargetGroupInput")).
+		Return(&aws_elb.CreateTargetGroupOutput{TargetGroups: []*aws_elb.TargetGroup{{TargetGroupArn: aws.String(targetGroupArn)}}}, nil)
+	mockElb.On("DescribeListeners", &aws_elb.DescribeListenersInput{LoadBalancerArn: aws.String(nlbArn)}).
+		Return(&aws_elb.DescribeListenersOutput{}, nil)
+	mockElb.On("CreateListener", mock.AnythingOfType("*elbv2.CreateListenerInput")).
+		Return(&aws_elb.CreateListenerOutput{}, nil)
+	mockElb.On("RegisterTargets", &aws_elb.RegisterTargetsInput{
+		TargetGroupArn: aws.String(targetGroupArn),
+		Targets:        []*aws_elb.TargetDescription{{Id: aws.String(instanceID)}},
+	}).Return(&aws_elb.RegisterTargetsOutput{}, nil)
+	mockElb.On("DeregisterTargets", &aws_elb.DeregisterTargetsInput{
+		TargetGroupArn: aws.String(targetGroupArn),
+		Targets:        []*aws_elb.TargetDescription{{Id: aws.String(instanceID)}},
+	}).Return(&aws_elb.DeregisterTargetsOutput{}, nil)
+
+	// when
+	updateErr := e.Update(controller.IngressEntries{})
+	stopErr := e.Stop()
+
+	// then
+	assert.NoError(t, updateErr)
+	assert.NoError(t, stopErr)
+	mockElb.AssertExpectations(t)
+}
+
+func TestRegistersNLBTargetsWithPortWhenTargetTypeIsIP(t *testing.T) {
+	// given
+	spec := NLBListenerSpec{Protocol: "TCP", ListenPort: 22, TargetPort: 32022}
+	podIP := "10.0.0.1"
+	e, _ := New(region, clusterName, 0, 0, TargetTypeIP, StaticPodIPFinder{podIP}, []NLBListenerSpec{spec}, "vpc-1234",
+		time.Millisecond*50, time.Millisecond*10, 0)
+	mockElb := &fakeElb{}
+	mockDNS := &fakeDNSResolver{}
+	stubResolvedDNS(mockDNS)
+	e.(*elb).awsElb = mockElb
+	e.(*elb).dnsResolver = mockDNS
+	nlbArn := "nlb-arn"
+	mockTaggedNLB(mockElb, "nlb-frontend", nlbArn)
+
+	targetGroupArn := "nlb-tg-arn"
+	mockElb.On("DescribeTargetGroups", &aws_elb.DescribeTargetGroupsInput{LoadBalancerArn: aws.String(nlbArn)}).
+		Return(&aws_elb.DescribeTargetGroupsOutput{}, nil)
+	mockElb.On("CreateTargetGroup", mock.AnythingOfType("*elbv2.CreateTargetGroupInput")).
+		Return(&aws_elb.CreateTargetGroupOutput{TargetGroups: []*aws_elb.TargetGroup{{TargetGroupArn: aws.String(targetGroupArn)}}}, nil)
+	mockElb.On("DescribeListeners", &aws_elb.DescribeListenersInput{LoadBalancerArn: aws.String(nlbArn)}).
+		Return(&aws_elb.DescribeListenersOutput{}, nil)
+	mockElb.On("CreateListener", mock.AnythingOfType("*elbv2.CreateListenerInput")).
+		Return(&aws_elb.CreateListenerOutput{}, nil)
+	mockElb.On("RegisterTargets", &aws_elb.RegisterTargetsInput{
+		TargetGroupArn: aws.String(targetGroupArn),
+		Targets:        []*aws_elb.TargetDescription{{Id: aws.String(podIP), Port: aws.Int64(spec.TargetPort)}},
+	}).Return(&aws_elb.RegisterTargetsOutput{}, nil)
+
+	// when
+	err := e.Update(controller.IngressEntries{})
+
+	// then
+	assert.NoError(t, err)
+	assert.NoError(t, e.Health())
+	mockElb.AssertExpectations(t)
+}
+
+func TestHealthReflectsPartialNLBReconcileFailure(t *testing.T) {
+	// given
+	spec := NLBListenerSpec{Protocol: "TCP", ListenPort: 22, TargetPort: 32022}
+	e, mockElb, mockMetadata := setupNLB(spec)
+	mockInstanceMetadata(mockMetadata, "cow")
+	mockTaggedNLB(mockElb, "nlb-frontend", "nlb-arn")
+	mockElb.On("DescribeTargetGroups", mock.AnythingOfType("*elbv2.DescribeTargetGroupsInput")).
+		Return(&aws_elb.DescribeTargetGroupsOutput{}, errors.New("throttled"))
+
+	// when
+	updateErr := e.Update(controller.IngressEntries{})
+
+	// then
+	assert.NoError(t, updateErr)
+	assert.Error(t, e.Health())
+}
+
+func TestHealthReportsWaitingForDNSUntilItResolves(t *testing.T) {
+	// given
+	e, mockElb, mockMetadata := setup()
+	mockDNS := &fakeDNSResolver{}
+	e.(*elb).dnsResolver = mockDNS
+	instanceID := "cow"
+	mockInstanceMetadata(mockMetadata, instanceID)
+	clusterFrontEnd := "cluster-frontend"
+	arn := "lb-arn"
+	mockLoadBalancers(mockElb, lb{name: clusterFrontEnd, scheme: elbInternalScheme, arn: arn})
+	mockClusterTags(mockElb,
+		lbTags{name: clusterFrontEnd, tags: []*aws_elb.Tag{{Key: aws.String(frontendTag), Value: aws.String(clusterName)}}},
+	)
+	mockRegisterTargets(mockElb, arn, instanceID)
+	mockDNS.On("LookupHost", elbDNSName).Return([]string{}, errors.New("NXDOMAIN")).Once()
+	mockDNS.On("LookupHost", elbDNSName).Return([]string{"10.0.0.1"}, nil)
+
+	// when
+	updateErr := e.Update(controller.IngressEntries{})
+
+	// then
+	assert.NoError(t, updateErr)
+	assert.EqualError(t, e.Health(), "waiting for DNS: elb-dnsname")
+
+	// when the background retry picks up the now-resolving name
+	time.Sleep(time.Millisecond * 50)
+
+	// then
+	assert.NoError(t, e.Health())
+}
+
+func TestHealthKeepsRetryingDNSPastTimeout(t *testing.T) {
+	// given
+	e, mockElb, mockMetadata := setup()
+	e.(*elb).dnsResolveTimeout = time.Millisecond * 20
+	e.(*elb).dnsRetryInterval = time.Millisecond * 5
+	mockDNS := &fakeDNSResolver{}
+	e.(*elb).dnsResolver = mockDNS
+	instanceID := "cow"
+	mockInstanceMetadata(mockMetadata, instanceID)
+	clusterFrontEnd := "cluster-frontend"
+	arn := "lb-arn"
+	mockLoadBalancers(mockElb, lb{name: clusterFrontEnd, scheme: elbInternalScheme, arn: arn})
+	mockClusterTags(mockElb,
+		lbTags{name: clusterFrontEnd, tags: []*aws_elb.Tag{{Key: aws.String(frontendTag), Value: aws.String(clusterName)}}},
+	)
+	mockRegisterTargets(mockElb, arn, instanceID)
+	mockDNS.On("LookupHost", elbDNSName).Return([]string{}, errors.New("NXDOMAIN"))
+
+	// when
+	updateErr := e.Update(controller.IngressEntries{})
+
+	// then
+	assert.NoError(t, updateErr)
+	assert.EqualError(t, e.Health(), "waiting for DNS: elb-dnsname")
+
+	// when dnsResolveTimeout has elapsed and DNS still hasn't resolved
+	time.Sleep(time.Millisecond * 50)
+
+	// then Health() escalates its message instead of staying silently wedged
+	// on the last value it saw before giving up, proving the retry loop is
+	// still running rather than having abandoned itself at the deadline
+	assert.Contains(t, e.Health().Error(), "still waiting for DNS after")
+}
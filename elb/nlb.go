@@ -0,0 +1,233 @@
+package elb
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	aws_elb "github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+// networkLoadBalancerType is the elbv2 LoadBalancer.Type value for NLBs, as
+// opposed to "application" for ALBs.
+const networkLoadBalancerType = "network"
+
+// NLBHealthCheck declares the health check to configure on a reconciled NLB
+// target group.
+type NLBHealthCheck struct {
+	Protocol string
+	Port     int64
+	Path     string
+}
+
+// NLBListenerSpec declares a listener and target group that feed should
+// ensure exists on every NLB tagged for the cluster, so that the cluster can
+// be fronted with non-HTTP ports such as TCP 22 or UDP 53.
+type NLBListenerSpec struct {
+	Protocol    string
+	ListenPort  int64
+	TargetPort  int64
+	HealthCheck NLBHealthCheck
+}
+
+// NLBListenerSpecs binds the --nlb-listeners command line flag to a slice of
+// NLBListenerSpec, using the same comma-separated style as
+// cmd.CommaSeparatedValues, with each entry being a JSON-encoded
+// NLBListenerSpec.
+type NLBListenerSpecs []NLBListenerSpec
+
+// String returns the flag's current value.
+func (n *NLBListenerSpecs) String() string {
+	entries := make([]string, 0, len(*n))
+	for _, spec := range *n {
+		encoded, err := json.Marshal(spec)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, string(encoded))
+	}
+	return strings.Join(entries, ",")
+}
+
+// Set parses a comma-separated list of JSON-encoded NLBListenerSpec entries.
+func (n *NLBListenerSpecs) Set(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	var specs NLBListenerSpecs
+	for _, entry := range strings.Split(value, ",") {
+		var spec NLBListenerSpec
+		if err := json.Unmarshal([]byte(entry), &spec); err != nil {
+			return fmt.Errorf("invalid nlb-listeners entry %q: %v", entry, err)
+		}
+		specs = append(specs, spec)
+	}
+
+	*n = specs
+	return nil
+}
+
+// networkLoadBalancer is an NLB discovered as tagged for the cluster.
+type networkLoadBalancer struct {
+	name string
+	arn  string
+}
+
+// networkLoadBalancersFromTagged picks out the NLBs (Type=network) tagged
+// sky.uk/KubernetesClusterFrontend for clusterName from lbs/tags, as already
+// fetched by describeTaggedLoadBalancers for the current reconcile cycle.
+func networkLoadBalancersFromTagged(lbs []*aws_elb.LoadBalancer, tags []*aws_elb.TagDescription, clusterName string) []networkLoadBalancer {
+	var found []networkLoadBalancer
+	for i, l := range lbs {
+		if aws.StringValue(l.Type) != networkLoadBalancerType {
+			continue
+		}
+		if i >= len(tags) || !hasClusterTag(tags[i].Tags, clusterName) {
+			continue
+		}
+		found = append(found, networkLoadBalancer{
+			name: aws.StringValue(l.LoadBalancerName),
+			arn:  aws.StringValue(l.LoadBalancerArn),
+		})
+	}
+
+	return found
+}
+
+// reconcileNLBs ensures that every declared NLBListenerSpec has a matching
+// target group and listener on each discovered NLB, and that targetIDs are
+// registered against each spec's target group. It returns the target groups
+// successfully registered against, so that callers can deregister them on
+// shutdown, alongside any reconciliation errors keyed by NLB ARN.
+func reconcileNLBs(e awsElb, nlbs []networkLoadBalancer, specs []NLBListenerSpec, targetType TargetType, targetIDs []string, vpcID string) ([]registeredFrontend, map[string]error) {
+	errs := make(map[string]error)
+	var registered []registeredFrontend
+	for _, nlb := range nlbs {
+		nlbRegistered, err := reconcileNLB(e, nlb, specs, targetType, targetIDs, vpcID)
+		registered = append(registered, nlbRegistered...)
+		if err != nil {
+			errs[nlb.arn] = err
+		}
+	}
+	return registered, errs
+}
+
+func reconcileNLB(e awsElb, nlb networkLoadBalancer, specs []NLBListenerSpec, targetType TargetType, targetIDs []string, vpcID string) ([]registeredFrontend, error) {
+	var registered []registeredFrontend
+	for _, spec := range specs {
+		targetGroupArn, err := ensureTargetGroup(e, nlb, spec, targetType, vpcID)
+		if err != nil {
+			return registered, fmt.Errorf("unable to reconcile target group for %s:%d on %s: %v", spec.Protocol, spec.ListenPort, nlb.name, err)
+		}
+
+		if err := ensureListener(e, nlb, spec, targetGroupArn); err != nil {
+			return registered, fmt.Errorf("unable to reconcile listener for %s:%d on %s: %v", spec.Protocol, spec.ListenPort, nlb.name, err)
+		}
+
+		// ip-typed target groups require an explicit port per target, the
+		// same way registrationPorts does for the ALB/pre-existing path;
+		// instance-typed ones register on the target's own port like before.
+		var port *int64
+		if targetType == TargetTypeIP {
+			port = aws.Int64(spec.TargetPort)
+		}
+
+		_, err = e.RegisterTargets(&aws_elb.RegisterTargetsInput{
+			TargetGroupArn: aws.String(targetGroupArn),
+			Targets:        targetDescriptions(targetIDs, port),
+		})
+		if err != nil {
+			return registered, fmt.Errorf("unable to register targets for %s:%d on %s: %v", spec.Protocol, spec.ListenPort, nlb.name, err)
+		}
+
+		registered = append(registered, registeredFrontend{
+			name:           nlb.name,
+			targetGroupArn: targetGroupArn,
+			targetIDs:      targetIDs,
+		})
+	}
+	return registered, nil
+}
+
+func ensureTargetGroup(e awsElb, nlb networkLoadBalancer, spec NLBListenerSpec, targetType TargetType, vpcID string) (string, error) {
+	output, err := e.DescribeTargetGroups(&aws_elb.DescribeTargetGroupsInput{LoadBalancerArn: aws.String(nlb.arn)})
+	if err != nil {
+		return "", fmt.Errorf("unable to describe target groups: %v", err)
+	}
+
+	for _, group := range output.TargetGroups {
+		if aws.StringValue(group.Protocol) == spec.Protocol && aws.Int64Value(group.Port) == spec.TargetPort {
+			return aws.StringValue(group.TargetGroupArn), nil
+		}
+	}
+
+	created, err := e.CreateTargetGroup(&aws_elb.CreateTargetGroupInput{
+		Name:                aws.String(targetGroupName(nlb.name, spec)),
+		Protocol:            aws.String(spec.Protocol),
+		Port:                aws.Int64(spec.TargetPort),
+		VpcId:               aws.String(vpcID),
+		TargetType:          aws.String(string(targetType)),
+		HealthCheckProtocol: aws.String(spec.HealthCheck.Protocol),
+		HealthCheckPort:     healthCheckPort(spec.HealthCheck),
+		HealthCheckPath:     healthCheckPath(spec.HealthCheck),
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to create target group: %v", err)
+	}
+	if len(created.TargetGroups) == 0 {
+		return "", fmt.Errorf("no target group returned for %s", targetGroupName(nlb.name, spec))
+	}
+
+	return aws.StringValue(created.TargetGroups[0].TargetGroupArn), nil
+}
+
+func ensureListener(e awsElb, nlb networkLoadBalancer, spec NLBListenerSpec, targetGroupArn string) error {
+	output, err := e.DescribeListeners(&aws_elb.DescribeListenersInput{LoadBalancerArn: aws.String(nlb.arn)})
+	if err != nil {
+		return fmt.Errorf("unable to describe listeners: %v", err)
+	}
+
+	for _, listener := range output.Listeners {
+		if aws.Int64Value(listener.Port) == spec.ListenPort && aws.StringValue(listener.Protocol) == spec.Protocol {
+			return nil
+		}
+	}
+
+	_, err = e.CreateListener(&aws_elb.CreateListenerInput{
+		LoadBalancerArn: aws.String(nlb.arn),
+		Protocol:        aws.String(spec.Protocol),
+		Port:            aws.Int64(spec.ListenPort),
+		DefaultActions: []*aws_elb.Action{{
+			Type:           aws.String("forward"),
+			TargetGroupArn: aws.String(targetGroupArn),
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to create listener: %v", err)
+	}
+
+	return nil
+}
+
+func targetGroupName(nlbName string, spec NLBListenerSpec) string {
+	return fmt.Sprintf("%s-%s-%d", nlbName, strings.ToLower(spec.Protocol), spec.TargetPort)
+}
+
+// healthCheckPort returns the health check port to configure on the target
+// group, defaulting to "traffic-port" (health check each target on the port
+// it was registered on) when the spec leaves it unset.
+func healthCheckPort(hc NLBHealthCheck) *string {
+	if hc.Port == 0 {
+		return aws.String("traffic-port")
+	}
+	return aws.String(fmt.Sprintf("%d", hc.Port))
+}
+
+func healthCheckPath(hc NLBHealthCheck) *string {
+	if hc.Path == "" {
+		return nil
+	}
+	return aws.String(hc.Path)
+}
@@ -0,0 +1,138 @@
+package elb
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	aws_elb "github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sky-uk/feed/util/metrics"
+)
+
+// defaultTagCacheTTL is used when New is called without an explicit TTL.
+const defaultTagCacheTTL = 5 * time.Minute
+
+var (
+	tagCacheHits                prometheus.Counter
+	tagCacheMisses              prometheus.Counter
+	registerTagCacheMetricsOnce sync.Once
+)
+
+// registerTagCacheMetrics registers the tag cache's hit/miss counters the
+// first time it's called, using whatever const labels are configured at that
+// point. It's called from New so that metrics.SetConstLabels has already run.
+func registerTagCacheMetrics() {
+	registerTagCacheMetricsOnce.Do(func() {
+		tagCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   "feed",
+			Subsystem:   "elb",
+			Name:        "tag_cache_hits_total",
+			Help:        "Number of DescribeTags lookups served from the per-ARN tag cache instead of the AWS API.",
+			ConstLabels: metrics.ConstLabels(),
+		})
+		tagCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   "feed",
+			Subsystem:   "elb",
+			Name:        "tag_cache_misses_total",
+			Help:        "Number of DescribeTags lookups that missed the per-ARN tag cache and queried the AWS API.",
+			ConstLabels: metrics.ConstLabels(),
+		})
+		prometheus.MustRegister(tagCacheHits, tagCacheMisses)
+	})
+}
+
+// tagCacheEntry is a load balancer's tags as of fetchedAt.
+type tagCacheEntry struct {
+	tags      []*aws_elb.Tag
+	fetchedAt time.Time
+}
+
+// tagCache caches DescribeTags results per load balancer ARN for ttl, so that
+// Update doesn't re-query tags for every known ARN on every reconcile, which
+// is the main contributor to elbv2 DescribeTags throttling in accounts with
+// many load balancers.
+type tagCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]tagCacheEntry
+}
+
+func newTagCache(ttl time.Duration) *tagCache {
+	if ttl == 0 {
+		ttl = defaultTagCacheTTL
+	}
+	return &tagCache{ttl: ttl, entries: make(map[string]tagCacheEntry)}
+}
+
+// get returns the cached tags for arn, if present and not yet expired.
+func (c *tagCache) get(arn string) ([]*aws_elb.Tag, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[arn]
+	if !ok || time.Since(entry.fetchedAt) > c.ttl {
+		tagCacheMisses.Inc()
+		return nil, false
+	}
+	tagCacheHits.Inc()
+	return entry.tags, true
+}
+
+// set stores tags for arn, replacing any existing entry.
+func (c *tagCache) set(arn string, tags []*aws_elb.Tag) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[arn] = tagCacheEntry{tags: tags, fetchedAt: time.Now()}
+}
+
+// prune drops cached entries for ARNs no longer present in lbs, so that load
+// balancers removed from the account don't leave stale entries behind
+// indefinitely.
+func (c *tagCache) prune(lbs []*aws_elb.LoadBalancer) {
+	current := make(map[string]bool, len(lbs))
+	for _, l := range lbs {
+		current[aws.StringValue(l.LoadBalancerArn)] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for arn := range c.entries {
+		if !current[arn] {
+			delete(c.entries, arn)
+		}
+	}
+}
+
+// describeTagsCached returns the TagDescription for each of lbs, in the same
+// order as lbs (matching describeTags' existing contract), serving
+// not-yet-expired entries from c instead of querying DescribeTags.
+func (c *tagCache) describeTagsCached(e awsElb, lbs []*aws_elb.LoadBalancer) ([]*aws_elb.TagDescription, error) {
+	result := make([]*aws_elb.TagDescription, len(lbs))
+	var toFetch []*aws_elb.LoadBalancer
+	var toFetchIdx []int
+
+	for i, l := range lbs {
+		arn := aws.StringValue(l.LoadBalancerArn)
+		if tags, ok := c.get(arn); ok {
+			result[i] = &aws_elb.TagDescription{ResourceArn: aws.String(arn), Tags: tags}
+			continue
+		}
+		toFetch = append(toFetch, l)
+		toFetchIdx = append(toFetchIdx, i)
+	}
+
+	fetched, err := describeTags(e, toFetch)
+	if err != nil {
+		return nil, err
+	}
+
+	for j, idx := range toFetchIdx {
+		td := fetched[j]
+		result[idx] = td
+		c.set(aws.StringValue(lbs[idx].LoadBalancerArn), td.Tags)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import "fmt"
+
+// ELBTargetType binds the --elb-target-type command line flag to one of the
+// supported elb.TargetType values, in the same style as CommaSeparatedValues.
+type ELBTargetType string
+
+// String returns the flag's current value.
+func (t *ELBTargetType) String() string {
+	return string(*t)
+}
+
+// Set validates and binds the flag value, which must be "ip" or "instance".
+func (t *ELBTargetType) Set(value string) error {
+	switch value {
+	case "ip", "instance":
+		*t = ELBTargetType(value)
+		return nil
+	default:
+		return fmt.Errorf("invalid elb-target-type %q: must be \"ip\" or \"instance\"", value)
+	}
+}
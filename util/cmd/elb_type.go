@@ -0,0 +1,23 @@
+package cmd
+
+import "fmt"
+
+// ELBType binds the --elb-type command line flag to the flavour of ELB
+// updater feed should run, in the same style as CommaSeparatedValues.
+type ELBType string
+
+// String returns the flag's current value.
+func (t *ELBType) String() string {
+	return string(*t)
+}
+
+// Set validates and binds the flag value, which must be "classic" or "v2".
+func (t *ELBType) Set(value string) error {
+	switch value {
+	case "classic", "v2":
+		*t = ELBType(value)
+		return nil
+	default:
+		return fmt.Errorf("invalid elb-type %q: must be \"classic\" or \"v2\"", value)
+	}
+}
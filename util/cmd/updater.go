@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sky-uk/feed/classicelb"
+	"github.com/sky-uk/feed/controller"
+	"github.com/sky-uk/feed/elb"
+)
+
+// ELBUpdaterConfig collects the flag-derived configuration needed to build
+// the front end updater selected by --elb-type, bridging the ELBType,
+// ELBTargetType and elb.NLBListenerSpecs flag values parsed on the command
+// line into the corresponding New(...) call.
+type ELBUpdaterConfig struct {
+	Region              string
+	ClusterFrontEndName string
+	ExpectedNumber      int
+	DrainDelay          time.Duration
+	ELBType             ELBType
+	TargetType          ELBTargetType
+	PodIPFinder         elb.PodIPFinder
+	NLBListeners        elb.NLBListenerSpecs
+	VpcID               string
+	DNSResolveTimeout   time.Duration
+	DNSRetryInterval    time.Duration
+	TagCacheTTL         time.Duration
+}
+
+// NewELBUpdater builds the controller.Updater selected by cfg.ELBType:
+// classicelb.New for "classic", or elb.New for "v2" (the default when
+// ELBType is unset). Classic ELBs don't support NLB listeners or ip-typed
+// targets, so cfg.TargetType/PodIPFinder/NLBListeners/VpcID are ignored when
+// ELBType is "classic".
+func NewELBUpdater(cfg ELBUpdaterConfig) (controller.Updater, error) {
+	elbType := cfg.ELBType
+	if elbType == "" {
+		elbType = "v2"
+	}
+
+	switch elbType {
+	case "classic":
+		return classicelb.New(cfg.Region, cfg.ClusterFrontEndName, cfg.ExpectedNumber, cfg.DrainDelay)
+	case "v2":
+		return elb.New(cfg.Region, cfg.ClusterFrontEndName, cfg.ExpectedNumber, cfg.DrainDelay,
+			elb.TargetType(cfg.TargetType), cfg.PodIPFinder, cfg.NLBListeners, cfg.VpcID,
+			cfg.DNSResolveTimeout, cfg.DNSRetryInterval, cfg.TagCacheTTL)
+	default:
+		return nil, fmt.Errorf("unsupported elb-type %q", elbType)
+	}
+}
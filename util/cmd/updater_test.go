@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/sky-uk/feed/elb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestELBTypeSetAcceptsClassicAndV2(t *testing.T) {
+	var elbType ELBType
+
+	assert.NoError(t, elbType.Set("classic"))
+	assert.Equal(t, "classic", elbType.String())
+
+	assert.NoError(t, elbType.Set("v2"))
+	assert.Equal(t, "v2", elbType.String())
+}
+
+func TestELBTypeSetRejectsUnknownValue(t *testing.T) {
+	var elbType ELBType
+
+	err := elbType.Set("alb")
+	assert.Error(t, err)
+}
+
+func TestELBTargetTypeSetAcceptsIPAndInstance(t *testing.T) {
+	var targetType ELBTargetType
+
+	assert.NoError(t, targetType.Set("ip"))
+	assert.Equal(t, "ip", targetType.String())
+
+	assert.NoError(t, targetType.Set("instance"))
+	assert.Equal(t, "instance", targetType.String())
+}
+
+func TestELBTargetTypeSetRejectsUnknownValue(t *testing.T) {
+	var targetType ELBTargetType
+
+	err := targetType.Set("pod")
+	assert.Error(t, err)
+}
+
+func TestNewELBUpdaterBuildsClassicELBWhenSelected(t *testing.T) {
+	updater, err := NewELBUpdater(ELBUpdaterConfig{
+		Region:              "eu-west-1",
+		ClusterFrontEndName: "cluster-frontend",
+		ELBType:             "classic",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "classic elb frontend", updater.String())
+}
+
+func TestNewELBUpdaterBuildsV2ELBByDefault(t *testing.T) {
+	updater, err := NewELBUpdater(ELBUpdaterConfig{
+		Region:              "eu-west-1",
+		ClusterFrontEndName: "cluster-frontend",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "elb frontend", updater.String())
+}
+
+func TestNewELBUpdaterThreadsTargetTypeAndNLBListenersToV2ELB(t *testing.T) {
+	_, err := NewELBUpdater(ELBUpdaterConfig{
+		Region:              "eu-west-1",
+		ClusterFrontEndName: "cluster-frontend",
+		ELBType:             "v2",
+		TargetType:          "ip",
+		NLBListeners:        elb.NLBListenerSpecs{{Protocol: "TCP", ListenPort: 22, TargetPort: 32022}},
+	})
+
+	// no pod IP finder and no VPC ID configured for ip target type/NLB
+	// listeners, so New is expected to reject it rather than NewELBUpdater
+	// silently dropping the flag values
+	assert.Error(t, err)
+}
@@ -0,0 +1,30 @@
+// Package metrics provides shared Prometheus helpers used by the various
+// feed components so that metrics are labelled consistently.
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	constLabelsMu sync.RWMutex
+	constLabels   = make(prometheus.Labels)
+)
+
+// SetConstLabels sets the constant labels that are attached to every metric
+// registered through this package, such as the feed instance name.
+func SetConstLabels(labels prometheus.Labels) {
+	constLabelsMu.Lock()
+	defer constLabelsMu.Unlock()
+	constLabels = labels
+}
+
+// ConstLabels returns the constant labels configured via SetConstLabels, for
+// use by packages registering their own collectors.
+func ConstLabels() prometheus.Labels {
+	constLabelsMu.RLock()
+	defer constLabelsMu.RUnlock()
+	return constLabels
+}